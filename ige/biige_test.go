@@ -0,0 +1,166 @@
+package ige
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/rand"
+	"testing"
+)
+
+//the OpenSSL IGE paper (see BiIGEEncrypt's doc comment) does not publish
+//fixed bi-IGE test vectors the way it does for plain IGE, so this file
+//checks the construction via round-trip and garble-propagation tests
+//instead.
+
+func TestBiIGERoundTrip(t *testing.T) {
+	key := make([]byte, 32)
+	iv1 := make([]byte, 2*aes.BlockSize)
+	iv2 := make([]byte, 2*aes.BlockSize)
+	plaintext := make([]byte, 4*aes.BlockSize)
+	for _, b := range [][]byte{key, iv1, iv2, plaintext} {
+		if _, err := rand.Read(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext := make([]byte, len(plaintext))
+	if err := BiIGEEncrypt(block, iv1, iv2, ciphertext, plaintext); err != nil {
+		t.Fatalf("BiIGEEncrypt: %v", err)
+	}
+
+	decrypted := make([]byte, len(ciphertext))
+	if err := BiIGEDecrypt(block, iv1, iv2, decrypted, ciphertext); err != nil {
+		t.Fatalf("BiIGEDecrypt: %v", err)
+	}
+
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatal("decrypted output does not match original plaintext")
+	}
+}
+
+func TestBiIGEGarbleIsBidirectional(t *testing.T) {
+	key := make([]byte, 32)
+	iv1 := make([]byte, 2*aes.BlockSize)
+	iv2 := make([]byte, 2*aes.BlockSize)
+	plaintext := make([]byte, 6*aes.BlockSize)
+	for _, b := range [][]byte{key, iv1, iv2, plaintext} {
+		if _, err := rand.Read(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext := make([]byte, len(plaintext))
+	if err := BiIGEEncrypt(block, iv1, iv2, ciphertext, plaintext); err != nil {
+		t.Fatalf("BiIGEEncrypt: %v", err)
+	}
+
+	//flip a bit in the first block; in plain IGE this only garbles
+	//blocks at and after it, but bi-IGE's reverse pass should garble the
+	//whole message, including the last block.
+	tampered := make([]byte, len(ciphertext))
+	copy(tampered, ciphertext)
+	tampered[0] ^= 0x01
+
+	decrypted := make([]byte, len(tampered))
+	if err := BiIGEDecrypt(block, iv1, iv2, decrypted, tampered); err != nil {
+		t.Fatalf("BiIGEDecrypt: %v", err)
+	}
+
+	lastBlock := len(plaintext) - aes.BlockSize
+	if bytes.Equal(decrypted[lastBlock:], plaintext[lastBlock:]) {
+		t.Fatal("tampering with the first ciphertext block did not garble the last plaintext block")
+	}
+}
+
+func TestNewBiIGERoundTrip(t *testing.T) {
+	key := make([]byte, 32)
+	iv1 := make([]byte, 2*aes.BlockSize)
+	iv2 := make([]byte, 2*aes.BlockSize)
+	plaintext := make([]byte, 4*aes.BlockSize)
+	for _, b := range [][]byte{key, iv1, iv2, plaintext} {
+		if _, err := rand.Read(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext := make([]byte, len(plaintext))
+	NewBiIGEEncrypter(block, iv1, iv2).CryptBlocks(ciphertext, plaintext)
+
+	decrypted := make([]byte, len(ciphertext))
+	NewBiIGEDecrypter(block, iv1, iv2).CryptBlocks(decrypted, ciphertext)
+
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatal("decrypted output does not match original plaintext")
+	}
+}
+
+func TestNewBiIGEPanicsOnSecondCryptBlocksCall(t *testing.T) {
+	key := make([]byte, 32)
+	iv1 := make([]byte, 2*aes.BlockSize)
+	iv2 := make([]byte, 2*aes.BlockSize)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enc := NewBiIGEEncrypter(block, iv1, iv2)
+	buf := make([]byte, aes.BlockSize)
+	enc.CryptBlocks(buf, buf)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic on second CryptBlocks call")
+		}
+	}()
+	enc.CryptBlocks(buf, buf)
+}
+
+func TestNewBiIGEPanicsOnBadIV(t *testing.T) {
+	key := make([]byte, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := make([]byte, 2*aes.BlockSize)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic for IV length != 2 * block size")
+		}
+	}()
+	NewBiIGEEncrypter(block, make([]byte, 10), iv)
+}
+
+func TestBiIGEEncryptValidation(t *testing.T) {
+	key := make([]byte, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := make([]byte, 2*aes.BlockSize)
+
+	if err := BiIGEEncrypt(block, make([]byte, 10), iv, make([]byte, 16), make([]byte, 16)); err != ErrInvalidIV {
+		t.Fatalf("err = %v, want ErrInvalidIV", err)
+	}
+	if err := BiIGEEncrypt(block, iv, iv, make([]byte, 16), make([]byte, 10)); err != ErrNotFullBlocks {
+		t.Fatalf("err = %v, want ErrNotFullBlocks", err)
+	}
+	if err := BiIGEEncrypt(block, iv, iv, make([]byte, 8), make([]byte, 16)); err != ErrShortDst {
+		t.Fatalf("err = %v, want ErrShortDst", err)
+	}
+}
@@ -0,0 +1,185 @@
+// Package contentenc implements an IGE-based file/stream content
+// encryption scheme modeled on gocryptfs's design: the plaintext is split
+// into fixed-size blocks, and each block is encrypted under a fresh
+// random IV pair and authenticated with an HMAC tag bound to a per-file ID,
+// block index and a last-block marker, so that blocks cannot be reordered,
+// duplicated or spliced between files, and a run of trailing whole frames
+// cannot be silently dropped: the stream's actual final frame is the only
+// one whose tag was computed with the marker set, so truncating it away
+// leaves the new apparent last frame's tag mismatched.
+//
+// Each encrypted block is a self-contained frame:
+//
+//	iv (2 * block size) || ciphertext || tag (32 bytes)
+package contentenc
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+
+	"github.com/MidnightWonderer/IGE-go/ige"
+)
+
+// DefaultBlockSize is the default plaintext block size, matching
+// gocryptfs's default content-encryption block size.
+const DefaultBlockSize = 4096
+
+// FileIDSize is the length of the per-file identifier mixed into every
+// block's authentication tag.
+const FileIDSize = 16
+
+const tagSize = sha256.Size
+
+var (
+	// ErrBlockTooLarge is returned when a plaintext chunk passed to
+	// EncryptBlock exceeds the configured block size.
+	ErrBlockTooLarge = errors.New("contentenc: plaintext block exceeds configured block size")
+	// ErrShortFrame is returned when a ciphertext frame is too short to
+	// contain an IV and tag, or when the ciphertext between them is not a
+	// multiple of the cipher's block size.
+	ErrShortFrame = errors.New("contentenc: frame too short to contain IV and tag")
+	// ErrTagMismatch is returned when a frame's authentication tag does
+	// not match its fileID, block number, last-block marker, IV and
+	// ciphertext.
+	ErrTagMismatch = errors.New("contentenc: block authentication tag mismatch")
+	// ErrBadPadding is returned when a decrypted block's PKCS#7 padding
+	// is malformed.
+	ErrBadPadding = errors.New("contentenc: invalid PKCS#7 padding")
+)
+
+// FileID is a per-file/stream identifier mixed into every block's
+// authentication tag. Callers should generate one randomly (e.g. with
+// crypto/rand) per file and store it alongside the ciphertext.
+type FileID [FileIDSize]byte
+
+func ivSize(block cipher.Block) int { return 2 * block.BlockSize() }
+
+// checkBlockSize panics if blockSize is not a positive multiple of
+// block's block size. Encrypter/Decrypter rely on every non-final
+// plaintext chunk being exactly blockSize long and therefore already
+// block-aligned, so that PKCS#7 padding always adds exactly
+// block.BlockSize() bytes and every non-final frame has the same,
+// predictable length.
+func checkBlockSize(block cipher.Block, blockSize int) {
+	if blockSize <= 0 || blockSize%block.BlockSize() != 0 {
+		panic("contentenc: blockSize must be a positive multiple of the cipher's block size")
+	}
+}
+
+func blockNumBytes(n uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], n)
+	return b[:]
+}
+
+// blockTag computes
+// HMAC-SHA256(fileID || blockNum || final || iv || ciphertext). Binding
+// final (whether blockNum is the stream's last block) into the tag means a
+// block can't be re-authenticated after the real final block following it
+// has been truncated away.
+func blockTag(hmacKey []byte, fileID FileID, blockNum uint64, final bool, iv, ciphertext []byte) []byte {
+	m := hmac.New(sha256.New, hmacKey)
+	m.Write(fileID[:])
+	m.Write(blockNumBytes(blockNum))
+	if final {
+		m.Write([]byte{1})
+	} else {
+		m.Write([]byte{0})
+	}
+	m.Write(iv)
+	m.Write(ciphertext)
+	return m.Sum(nil)
+}
+
+// pkcs7Pad pads p to a multiple of blockSize. Per PKCS#7, input that is
+// already a multiple of blockSize still gets a full extra block of
+// padding, so padding can always be unambiguously removed again.
+func pkcs7Pad(p []byte, blockSize int) []byte {
+	padLen := blockSize - len(p)%blockSize
+	out := make([]byte, len(p)+padLen)
+	copy(out, p)
+	for i := len(p); i < len(out); i++ {
+		out[i] = byte(padLen)
+	}
+	return out
+}
+
+func pkcs7Unpad(p []byte, blockSize int) ([]byte, error) {
+	if len(p) == 0 || len(p)%blockSize != 0 {
+		return nil, ErrBadPadding
+	}
+	padLen := int(p[len(p)-1])
+	if padLen == 0 || padLen > blockSize || padLen > len(p) {
+		return nil, ErrBadPadding
+	}
+	for _, b := range p[len(p)-padLen:] {
+		if int(b) != padLen {
+			return nil, ErrBadPadding
+		}
+	}
+	return p[:len(p)-padLen], nil
+}
+
+// EncryptBlock encrypts the blockNum'th plaintext chunk of the stream
+// identified by fileID, returning a self-contained frame
+// iv || ciphertext || tag. plaintext is PKCS#7-padded to a multiple of
+// block's block size before encryption, so it may be shorter than
+// blockSize (e.g. a stream's final chunk) but must not exceed it.
+//
+// final must be true if and only if blockNum is the last block of the
+// stream; it is bound into the tag so that DecryptBlock can detect a
+// truncated stream (see the package doc).
+func EncryptBlock(block cipher.Block, hmacKey []byte, fileID FileID, blockNum uint64, final bool, blockSize int, plaintext []byte) ([]byte, error) {
+	if len(plaintext) > blockSize {
+		return nil, ErrBlockTooLarge
+	}
+
+	iv := make([]byte, ivSize(block))
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	padded := pkcs7Pad(plaintext, block.BlockSize())
+	ciphertext := make([]byte, len(padded))
+	ige.NewIGEEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+
+	tag := blockTag(hmacKey, fileID, blockNum, final, iv, ciphertext)
+
+	frame := make([]byte, 0, len(iv)+len(ciphertext)+len(tag))
+	frame = append(frame, iv...)
+	frame = append(frame, ciphertext...)
+	frame = append(frame, tag...)
+	return frame, nil
+}
+
+// DecryptBlock verifies and decrypts a frame produced by EncryptBlock for
+// the given blockNum, returning the original (unpadded) plaintext chunk.
+// final must match whatever the frame was encrypted with; a caller that
+// doesn't independently know where the stream ends should pass whatever it
+// observes (e.g. "no more frames followed this one") and rely on
+// ErrTagMismatch to reject a stream that was truncated right after this
+// frame.
+func DecryptBlock(block cipher.Block, hmacKey []byte, fileID FileID, blockNum uint64, final bool, frame []byte) ([]byte, error) {
+	ivLen := ivSize(block)
+	if len(frame) < ivLen+tagSize || (len(frame)-ivLen-tagSize)%block.BlockSize() != 0 {
+		return nil, ErrShortFrame
+	}
+
+	iv := frame[:ivLen]
+	ciphertext := frame[ivLen : len(frame)-tagSize]
+	wantTag := frame[len(frame)-tagSize:]
+
+	gotTag := blockTag(hmacKey, fileID, blockNum, final, iv, ciphertext)
+	if !hmac.Equal(gotTag, wantTag) {
+		return nil, ErrTagMismatch
+	}
+
+	padded := make([]byte, len(ciphertext))
+	ige.NewIGEDecrypter(block, iv).CryptBlocks(padded, ciphertext)
+
+	return pkcs7Unpad(padded, block.BlockSize())
+}
@@ -0,0 +1,246 @@
+package contentenc
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+func newAES(t *testing.T) (key []byte) {
+	t.Helper()
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestEncryptDecryptBlockRoundTrip(t *testing.T) {
+	key := newAES(t)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmacKey := newAES(t)
+	var fileID FileID
+	rand.Read(fileID[:])
+
+	plaintext := []byte("hello, contentenc")
+	frame, err := EncryptBlock(block, hmacKey, fileID, 3, true, DefaultBlockSize, plaintext)
+	if err != nil {
+		t.Fatalf("EncryptBlock: %v", err)
+	}
+
+	got, err := DecryptBlock(block, hmacKey, fileID, 3, true, frame)
+	if err != nil {
+		t.Fatalf("DecryptBlock: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("got %q, want %q", got, plaintext)
+	}
+}
+
+func TestDecryptBlockRejectsWrongBlockNum(t *testing.T) {
+	key := newAES(t)
+	block, _ := aes.NewCipher(key)
+	hmacKey := newAES(t)
+	var fileID FileID
+
+	frame, err := EncryptBlock(block, hmacKey, fileID, 0, true, DefaultBlockSize, []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecryptBlock(block, hmacKey, fileID, 1, true, frame); err != ErrTagMismatch {
+		t.Fatalf("err = %v, want ErrTagMismatch", err)
+	}
+}
+
+func TestDecryptBlockRejectsWrongFileID(t *testing.T) {
+	key := newAES(t)
+	block, _ := aes.NewCipher(key)
+	hmacKey := newAES(t)
+	var fileID, otherFileID FileID
+	otherFileID[0] = 1
+
+	frame, err := EncryptBlock(block, hmacKey, fileID, 0, true, DefaultBlockSize, []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecryptBlock(block, hmacKey, otherFileID, 0, true, frame); err != ErrTagMismatch {
+		t.Fatalf("err = %v, want ErrTagMismatch", err)
+	}
+}
+
+func TestEncryptBlockRejectsOversizedChunk(t *testing.T) {
+	key := newAES(t)
+	block, _ := aes.NewCipher(key)
+	hmacKey := newAES(t)
+	var fileID FileID
+
+	if _, err := EncryptBlock(block, hmacKey, fileID, 0, true, 16, make([]byte, 17)); err != ErrBlockTooLarge {
+		t.Fatalf("err = %v, want ErrBlockTooLarge", err)
+	}
+}
+
+func TestDecryptBlockRejectsWrongFinal(t *testing.T) {
+	key := newAES(t)
+	block, _ := aes.NewCipher(key)
+	hmacKey := newAES(t)
+	var fileID FileID
+
+	frame, err := EncryptBlock(block, hmacKey, fileID, 0, false, DefaultBlockSize, []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecryptBlock(block, hmacKey, fileID, 0, true, frame); err != ErrTagMismatch {
+		t.Fatalf("err = %v, want ErrTagMismatch", err)
+	}
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	key := newAES(t)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmacKey := newAES(t)
+	var fileID FileID
+	rand.Read(fileID[:])
+
+	for _, size := range []int{0, 1, 15, DefaultBlockSize - 1, DefaultBlockSize, DefaultBlockSize + 1, 3*DefaultBlockSize + 500} {
+		plaintext := make([]byte, size)
+		rand.Read(plaintext)
+
+		var ciphertext bytes.Buffer
+		enc := NewEncrypter(&ciphertext, block, hmacKey, fileID)
+		if _, err := enc.Write(plaintext); err != nil {
+			t.Fatalf("size %d: Write: %v", size, err)
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatalf("size %d: Close: %v", size, err)
+		}
+
+		dec := NewDecrypter(&ciphertext, block, hmacKey, fileID)
+		got, err := io.ReadAll(dec)
+		if err != nil {
+			t.Fatalf("size %d: ReadAll: %v", size, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Fatalf("size %d: round trip mismatch", size)
+		}
+	}
+}
+
+func TestStreamEmptyProducesNoFrames(t *testing.T) {
+	key := newAES(t)
+	block, _ := aes.NewCipher(key)
+	hmacKey := newAES(t)
+	var fileID FileID
+
+	var ciphertext bytes.Buffer
+	enc := NewEncrypter(&ciphertext, block, hmacKey, fileID)
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if ciphertext.Len() != 0 {
+		t.Fatalf("empty stream produced %d bytes of ciphertext, want 0", ciphertext.Len())
+	}
+}
+
+// TestStreamDetectsTruncation checks that dropping a run of whole trailing
+// frames from a multi-block stream is detected, rather than Decrypter
+// silently handing back a truncated-but-otherwise-valid plaintext prefix.
+func TestStreamDetectsTruncation(t *testing.T) {
+	key := newAES(t)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmacKey := newAES(t)
+	var fileID FileID
+	rand.Read(fileID[:])
+
+	const blockSize = 16
+	plaintext := make([]byte, blockSize*4)
+	rand.Read(plaintext)
+
+	var ciphertext bytes.Buffer
+	enc := NewEncrypterSize(&ciphertext, block, hmacKey, fileID, blockSize)
+	if _, err := enc.Write(plaintext); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	frameSize := ivSize(block) + blockSize + block.BlockSize() + tagSize
+	full := ciphertext.Bytes()
+	if len(full) <= frameSize {
+		t.Fatalf("test setup produced only one frame, can't truncate a whole trailing frame")
+	}
+	truncated := full[:len(full)-frameSize]
+
+	dec := NewDecrypterSize(bytes.NewReader(truncated), block, hmacKey, fileID, blockSize)
+	if _, err := io.ReadAll(dec); err != ErrTagMismatch {
+		t.Fatalf("err = %v, want ErrTagMismatch for a stream truncated by a whole trailing frame", err)
+	}
+}
+
+func TestStreamRejectsUnalignedBlockSize(t *testing.T) {
+	key := newAES(t)
+	block, _ := aes.NewCipher(key)
+	hmacKey := newAES(t)
+	var fileID FileID
+
+	assertPanics := func(name string, f func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("%s: expected panic for blockSize not a multiple of %d", name, block.BlockSize())
+			}
+		}()
+		f()
+	}
+
+	assertPanics("NewEncrypterSize", func() {
+		NewEncrypterSize(&bytes.Buffer{}, block, hmacKey, fileID, DefaultBlockSize+1)
+	})
+	assertPanics("NewDecrypterSize", func() {
+		NewDecrypterSize(bytes.NewReader(nil), block, hmacKey, fileID, DefaultBlockSize+1)
+	})
+}
+
+func TestStreamRoundTripUnalignedButValidBlockSize(t *testing.T) {
+	key := newAES(t)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmacKey := newAES(t)
+	var fileID FileID
+	rand.Read(fileID[:])
+
+	const blockSize = 4080 // 255 * aes.BlockSize, a multiple of 16 but not of DefaultBlockSize
+	plaintext := make([]byte, blockSize*2+37)
+	rand.Read(plaintext)
+
+	var ciphertext bytes.Buffer
+	enc := NewEncrypterSize(&ciphertext, block, hmacKey, fileID, blockSize)
+	if _, err := enc.Write(plaintext); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	dec := NewDecrypterSize(&ciphertext, block, hmacKey, fileID, blockSize)
+	got, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatal("round trip mismatch")
+	}
+}
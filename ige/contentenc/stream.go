@@ -0,0 +1,165 @@
+package contentenc
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"io"
+)
+
+// Encrypter implements io.Writer, splitting the written stream into
+// fixed-size plaintext blocks and writing each as an EncryptBlock frame to
+// the underlying writer. Bytes are buffered until a full block is
+// available; the final, possibly short, block is only emitted by Close,
+// which must be called after the last Write.
+type Encrypter struct {
+	w         io.Writer
+	block     cipher.Block
+	hmacKey   []byte
+	fileID    FileID
+	blockSize int
+	blockNum  uint64
+	buf       []byte
+	closed    bool
+}
+
+// NewEncrypter returns an Encrypter writing to w using DefaultBlockSize
+// plaintext blocks.
+func NewEncrypter(w io.Writer, block cipher.Block, hmacKey []byte, fileID FileID) *Encrypter {
+	return NewEncrypterSize(w, block, hmacKey, fileID, DefaultBlockSize)
+}
+
+// NewEncrypterSize is like NewEncrypter but allows a non-default plaintext
+// block size. blockSize must be a positive multiple of block's block
+// size, since frameSize below relies on every non-final chunk's padded
+// ciphertext being exactly blockSize + block.BlockSize() long; it panics
+// otherwise.
+func NewEncrypterSize(w io.Writer, block cipher.Block, hmacKey []byte, fileID FileID, blockSize int) *Encrypter {
+	checkBlockSize(block, blockSize)
+	return &Encrypter{w: w, block: block, hmacKey: hmacKey, fileID: fileID, blockSize: blockSize}
+}
+
+// Write always holds back at least one buffered byte (even if that leaves
+// a full blockSize in the buffer) so the last block actually written is
+// only ever flushed by Close, which alone knows it's final.
+func (e *Encrypter) Write(p []byte) (int, error) {
+	e.buf = append(e.buf, p...)
+	for len(e.buf) > e.blockSize {
+		if err := e.flush(e.buf[:e.blockSize], false); err != nil {
+			return 0, err
+		}
+		e.buf = e.buf[e.blockSize:]
+	}
+	return len(p), nil
+}
+
+func (e *Encrypter) flush(plaintext []byte, final bool) error {
+	frame, err := EncryptBlock(e.block, e.hmacKey, e.fileID, e.blockNum, final, e.blockSize, plaintext)
+	if err != nil {
+		return err
+	}
+	if _, err := e.w.Write(frame); err != nil {
+		return err
+	}
+	e.blockNum++
+	return nil
+}
+
+// Close flushes any buffered final block to the underlying writer, marked
+// as the stream's last block so Decrypter can detect trailing frames
+// being truncated away. An empty stream (no bytes ever written) produces
+// no output at all. Close is idempotent.
+func (e *Encrypter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	if len(e.buf) == 0 {
+		return nil
+	}
+	err := e.flush(e.buf, true)
+	e.buf = nil
+	return err
+}
+
+// Decrypter implements io.Reader, verifying and decrypting the frames
+// written by an Encrypter and reassembling the original plaintext stream.
+// blockSize must match the Encrypter's.
+//
+// r is wrapped in a bufio.Reader so that, after reading a frame, Decrypter
+// can peek for more data to determine whether that frame was really the
+// stream's last one, and reject the stream if an attacker truncated it
+// right after a non-final frame.
+type Decrypter struct {
+	r         *bufio.Reader
+	block     cipher.Block
+	hmacKey   []byte
+	fileID    FileID
+	blockSize int
+	blockNum  uint64
+	pending   []byte
+}
+
+// NewDecrypter returns a Decrypter reading frames from r using
+// DefaultBlockSize plaintext blocks.
+func NewDecrypter(r io.Reader, block cipher.Block, hmacKey []byte, fileID FileID) *Decrypter {
+	return NewDecrypterSize(r, block, hmacKey, fileID, DefaultBlockSize)
+}
+
+// NewDecrypterSize is like NewDecrypter but allows a non-default plaintext
+// block size; it must match the blockSize the stream was encrypted with,
+// and, as with NewEncrypterSize, must be a positive multiple of block's
+// block size.
+func NewDecrypterSize(r io.Reader, block cipher.Block, hmacKey []byte, fileID FileID, blockSize int) *Decrypter {
+	checkBlockSize(block, blockSize)
+	return &Decrypter{r: bufio.NewReader(r), block: block, hmacKey: hmacKey, fileID: fileID, blockSize: blockSize}
+}
+
+// frameSize is the byte length of every frame except possibly the last:
+// non-final plaintext chunks are always exactly blockSize, and
+// EncryptBlock's PKCS#7 padding always adds a full block.BlockSize() of
+// padding to already block-aligned input, so their ciphertext is always
+// exactly blockSize + block.BlockSize() long.
+func (d *Decrypter) frameSize() int {
+	return ivSize(d.block) + d.blockSize + d.block.BlockSize() + tagSize
+}
+
+func (d *Decrypter) Read(p []byte) (int, error) {
+	if len(d.pending) == 0 {
+		if err := d.fill(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+// fill reads and decrypts the next frame into d.pending, or returns io.EOF
+// once the underlying reader is exhausted.
+func (d *Decrypter) fill() error {
+	frame := make([]byte, d.frameSize())
+	n, err := io.ReadFull(d.r, frame)
+	switch {
+	case err == io.EOF:
+		return io.EOF
+	case err == io.ErrUnexpectedEOF:
+		frame = frame[:n] // final, short block
+	case err != nil:
+		return err
+	}
+
+	// Peek past the frame just read: if nothing follows, this is really
+	// the stream's last frame, and DecryptBlock must see a tag computed
+	// with final=true or reject it — otherwise an attacker could have
+	// truncated a run of whole trailing frames undetected.
+	_, peekErr := d.r.Peek(1)
+	final := peekErr != nil
+
+	plaintext, err := DecryptBlock(d.block, d.hmacKey, d.fileID, d.blockNum, final, frame)
+	if err != nil {
+		return err
+	}
+	d.blockNum++
+	d.pending = plaintext
+	return nil
+}
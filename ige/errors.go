@@ -0,0 +1,79 @@
+package ige
+
+import (
+	"crypto/cipher"
+	"errors"
+)
+
+var (
+	// ErrInvalidIV is returned when an IV's length does not equal
+	// 2 * the underlying Block's block size.
+	ErrInvalidIV = errors.New("ige: IV length must equal 2 * block size")
+	// ErrNotFullBlocks is returned when src's length is not a multiple of
+	// the underlying Block's block size.
+	ErrNotFullBlocks = errors.New("ige: input not full blocks")
+	// ErrShortDst is returned when dst is smaller than src.
+	ErrShortDst = errors.New("ige: output smaller than input")
+)
+
+// NewIGEEncrypterErr is the non-panicking counterpart of NewIGEEncrypter,
+// for callers processing attacker-controlled IVs who would otherwise need
+// to recover() from a bad length.
+func NewIGEEncrypterErr(b cipher.Block, iv []byte) (cipher.BlockMode, error) {
+	if len(iv) != b.BlockSize()*2 {
+		return nil, ErrInvalidIV
+	}
+	return (*igeEncrypter)(newIGE(b, iv)), nil
+}
+
+// NewIGEDecrypterErr is the non-panicking counterpart of NewIGEDecrypter,
+// for callers processing attacker-controlled IVs who would otherwise need
+// to recover() from a bad length.
+func NewIGEDecrypterErr(b cipher.Block, iv []byte) (cipher.BlockMode, error) {
+	if len(iv) != b.BlockSize()*2 {
+		return nil, ErrInvalidIV
+	}
+	return (*igeDecrypter)(newIGE(b, iv)), nil
+}
+
+// EncryptBlocks encrypts src into dst in IGE mode using b and iv in a
+// single call, returning an error instead of panicking on bad input. It
+// builds the same internal state NewIGEEncrypter does, so it allocates as
+// much as NewIGEEncrypter followed by one CryptBlocks call. iv is copied
+// into that internal scratch space; EncryptBlocks never modifies iv and
+// retains no reference to it after returning.
+func EncryptBlocks(b cipher.Block, iv, dst, src []byte) error {
+	blockSize := b.BlockSize()
+	if len(iv) != blockSize*2 {
+		return ErrInvalidIV
+	}
+	if len(src)%blockSize != 0 {
+		return ErrNotFullBlocks
+	}
+	if len(dst) < len(src) {
+		return ErrShortDst
+	}
+	(*igeEncrypter)(newIGE(b, iv)).CryptBlocks(dst, src)
+	return nil
+}
+
+// DecryptBlocks decrypts src into dst in IGE mode using b and iv in a
+// single call, returning an error instead of panicking on bad input. It
+// builds the same internal state NewIGEDecrypter does, so it allocates as
+// much as NewIGEDecrypter followed by one CryptBlocks call. iv is copied
+// into that internal scratch space; DecryptBlocks never modifies iv and
+// retains no reference to it after returning.
+func DecryptBlocks(b cipher.Block, iv, dst, src []byte) error {
+	blockSize := b.BlockSize()
+	if len(iv) != blockSize*2 {
+		return ErrInvalidIV
+	}
+	if len(src)%blockSize != 0 {
+		return ErrNotFullBlocks
+	}
+	if len(dst) < len(src) {
+		return ErrShortDst
+	}
+	(*igeDecrypter)(newIGE(b, iv)).CryptBlocks(dst, src)
+	return nil
+}
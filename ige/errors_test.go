@@ -0,0 +1,85 @@
+package ige
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestNewIGEEncrypterErrInvalidIV(t *testing.T) {
+	block, _ := aes.NewCipher(make([]byte, 16))
+	if _, err := NewIGEEncrypterErr(block, make([]byte, 10)); err != ErrInvalidIV {
+		t.Fatalf("err = %v, want ErrInvalidIV", err)
+	}
+}
+
+func TestNewIGEDecrypterErrInvalidIV(t *testing.T) {
+	block, _ := aes.NewCipher(make([]byte, 16))
+	if _, err := NewIGEDecrypterErr(block, make([]byte, 10)); err != ErrInvalidIV {
+		t.Fatalf("err = %v, want ErrInvalidIV", err)
+	}
+}
+
+func TestEncryptDecryptBlocksRoundTrip(t *testing.T) {
+	key := make([]byte, 16)
+	iv := make([]byte, 2*aes.BlockSize)
+	plaintext := make([]byte, 3*aes.BlockSize)
+	for _, b := range [][]byte{key, iv, plaintext} {
+		rand.Read(b)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext := make([]byte, len(plaintext))
+	if err := EncryptBlocks(block, iv, ciphertext, plaintext); err != nil {
+		t.Fatalf("EncryptBlocks: %v", err)
+	}
+
+	decrypted := make([]byte, len(ciphertext))
+	if err := DecryptBlocks(block, iv, decrypted, ciphertext); err != nil {
+		t.Fatalf("DecryptBlocks: %v", err)
+	}
+
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatal("DecryptBlocks(EncryptBlocks(p)) != p")
+	}
+}
+
+func TestEncryptBlocksValidation(t *testing.T) {
+	block, _ := aes.NewCipher(make([]byte, 16))
+	iv := make([]byte, 2*aes.BlockSize)
+
+	if err := EncryptBlocks(block, make([]byte, 10), make([]byte, 16), make([]byte, 16)); err != ErrInvalidIV {
+		t.Fatalf("err = %v, want ErrInvalidIV", err)
+	}
+	if err := EncryptBlocks(block, iv, make([]byte, 16), make([]byte, 10)); err != ErrNotFullBlocks {
+		t.Fatalf("err = %v, want ErrNotFullBlocks", err)
+	}
+	if err := EncryptBlocks(block, iv, make([]byte, 8), make([]byte, 16)); err != ErrShortDst {
+		t.Fatalf("err = %v, want ErrShortDst", err)
+	}
+}
+
+func TestEncryptBlocksDoesNotMutateIV(t *testing.T) {
+	block, _ := aes.NewCipher(make([]byte, 16))
+	iv := make([]byte, 2*aes.BlockSize)
+	rand.Read(iv)
+	ivCopy := make([]byte, len(iv))
+	copy(ivCopy, iv)
+
+	plaintext := make([]byte, aes.BlockSize)
+	rand.Read(plaintext)
+	dst := make([]byte, len(plaintext))
+
+	if err := EncryptBlocks(block, iv, dst, plaintext); err != nil {
+		t.Fatalf("EncryptBlocks: %v", err)
+	}
+
+	if !bytes.Equal(iv, ivCopy) {
+		t.Fatal("EncryptBlocks mutated its iv argument")
+	}
+}
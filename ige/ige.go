@@ -19,7 +19,10 @@ THE SOFTWARE.*/
 
 package ige
 
-import "crypto/cipher"
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+)
 
 type ige struct {
 	b              cipher.Block
@@ -59,13 +62,9 @@ func cryptBlocks(dst, src []byte, x *igeEncrypter, cryptFunc func([]byte, []byte
 
 	for len(src) > 0 {
 		//use lastciphertext as scratch memory
-		for i := 0; i < x.blockSize; i++ {
-			x.lastciphertext[i] ^= src[i]
-		}
+		subtle.XORBytes(x.lastciphertext, x.lastciphertext, src)
 		cryptFunc(x.lastciphertext, x.lastciphertext) //x.b.Encrypt
-		for i := 0; i < x.blockSize; i++ {
-			x.lastciphertext[i] ^= x.lastplaintext[i]
-		}
+		subtle.XORBytes(x.lastciphertext, x.lastciphertext, x.lastplaintext)
 
 		//update internal state
 		copy(x.lastplaintext, src)
@@ -0,0 +1,191 @@
+// Package mtproto implements the MTProto 2.0 message-key/AES-key
+// derivation and framing used by Telegram clients on top of the raw
+// ige.NewIGEEncrypter/ige.NewIGEDecrypter primitive.
+//
+// See https://core.telegram.org/mtproto/description#defining-aes-key-and-initialization-vector
+// for the derivation this package implements.
+package mtproto
+
+import (
+	"crypto/aes"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/MidnightWonderer/IGE-go/ige"
+)
+
+// Direction identifies which side of the connection is encrypting, since
+// MTProto 2.0 derives different aes_key/aes_iv pairs for each direction
+// from the same auth_key.
+type Direction int
+
+const (
+	ClientToServer Direction = iota
+	ServerToClient
+)
+
+// x is the offset used in the key derivation below, per the spec.
+func (d Direction) x() int {
+	if d == ServerToClient {
+		return 8
+	}
+	return 0
+}
+
+const (
+	authKeySize = 256 // 2048 bits
+	msgKeySize  = 16
+
+	minPadding = 12
+	maxPadding = 1024
+)
+
+var (
+	// ErrInvalidAuthKeySize is returned when auth_key is not 2048 bits.
+	ErrInvalidAuthKeySize = errors.New("mtproto: auth_key must be 256 bytes (2048 bits)")
+	// ErrInvalidMsgKeySize is returned when msg_key is not 16 bytes.
+	ErrInvalidMsgKeySize = errors.New("mtproto: msg_key must be 16 bytes")
+	// ErrMsgKeyMismatch is returned by Decrypt when the msg_key recomputed
+	// from the decrypted plaintext doesn't match the msg_key supplied by
+	// the caller, meaning the ciphertext or msg_key was tampered with.
+	ErrMsgKeyMismatch = errors.New("mtproto: msg_key does not match decrypted plaintext")
+)
+
+// msgKeyLarge computes SHA256(substr(auth_key, 88+x, 32) || plaintext).
+func msgKeyLarge(authKey, plaintext []byte, d Direction) []byte {
+	x := d.x()
+	h := sha256.New()
+	h.Write(authKey[88+x : 88+x+32])
+	h.Write(plaintext)
+	return h.Sum(nil)
+}
+
+// deriveKeys computes aes_key and aes_iv from auth_key, msg_key and
+// direction as defined by MTProto 2.0.
+func deriveKeys(authKey, msgKey []byte, d Direction) (aesKey, aesIV []byte) {
+	x := d.x()
+
+	ha := sha256.New()
+	ha.Write(msgKey)
+	ha.Write(authKey[x : x+36])
+	a := ha.Sum(nil)
+
+	hb := sha256.New()
+	hb.Write(authKey[40+x : 40+x+36])
+	hb.Write(msgKey)
+	b := hb.Sum(nil)
+
+	aesKey = make([]byte, 32)
+	copy(aesKey[0:8], a[0:8])
+	copy(aesKey[8:24], b[8:24])
+	copy(aesKey[24:32], a[24:32])
+
+	aesIV = make([]byte, 32)
+	copy(aesIV[0:8], b[0:8])
+	copy(aesIV[8:24], a[8:24])
+	copy(aesIV[24:32], b[24:32])
+
+	return aesKey, aesIV
+}
+
+// pad appends a random 12-1024 byte padding to plaintext so that the
+// result is a multiple of 16 bytes long, as MTProto 2.0 requires before
+// encryption. The padding length is randomized across the whole allowed
+// range (not just the minimum needed to reach the next 16-byte boundary)
+// so that ciphertext length doesn't closely track plaintext length, per
+// the spec's rationale for randomized padding; rnd supplies both the
+// padding length choice and the padding bytes themselves.
+func pad(plaintext []byte, rnd io.Reader) ([]byte, error) {
+	base := minPadding
+	if rem := (len(plaintext) + base) % 16; rem != 0 {
+		base += 16 - rem
+	}
+
+	padLen := base
+	if extraBlocks := (maxPadding - base) / 16; extraBlocks > 0 {
+		n, err := crand.Int(rnd, big.NewInt(int64(extraBlocks)+1))
+		if err != nil {
+			return nil, err
+		}
+		padLen += int(n.Int64()) * 16
+	}
+
+	padded := make([]byte, len(plaintext)+padLen)
+	copy(padded, plaintext)
+	if _, err := io.ReadFull(rnd, padded[len(plaintext):]); err != nil {
+		return nil, err
+	}
+	return padded, nil
+}
+
+// Encrypt derives aes_key/aes_iv for authKey and d, pads plaintext per
+// MTProto 2.0 using rnd (e.g. crypto/rand.Reader) and encrypts it with
+// AES-256 IGE. It returns the 16-byte msg_key alongside the ciphertext.
+//
+// plaintext must already contain the MTProto message header (salt,
+// session_id, message_id, seq_no and length) that a receiver needs to
+// locate the real payload inside the padded, decrypted result.
+func Encrypt(authKey []byte, d Direction, plaintext []byte, rnd io.Reader) (msgKey, ciphertext []byte, err error) {
+	if len(authKey) != authKeySize {
+		return nil, nil, ErrInvalidAuthKeySize
+	}
+
+	padded, err := pad(plaintext, rnd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	msgKey = msgKeyLarge(authKey, padded, d)[8:24]
+
+	aesKey, aesIV := deriveKeys(authKey, msgKey, d)
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ciphertext = make([]byte, len(padded))
+	ige.NewIGEEncrypter(block, aesIV).CryptBlocks(ciphertext, padded)
+
+	return msgKey, ciphertext, nil
+}
+
+// Decrypt derives aes_key/aes_iv for authKey, msgKey and d, decrypts
+// ciphertext with AES-256 IGE, and verifies that msgKey matches the
+// msg_key recomputed from the decrypted plaintext, returning
+// ErrMsgKeyMismatch if it doesn't. ciphertext comes straight off the wire,
+// so its length is never trusted: Decrypt returns ige.ErrNotFullBlocks
+// rather than panicking if it isn't a multiple of the AES block size.
+//
+// The returned plaintext still contains the 12-1024 bytes of random
+// padding Encrypt appended; the caller's message framing (the length
+// field in the MTProto header) determines where the real payload ends.
+func Decrypt(authKey []byte, d Direction, msgKey, ciphertext []byte) (plaintext []byte, err error) {
+	if len(authKey) != authKeySize {
+		return nil, ErrInvalidAuthKeySize
+	}
+	if len(msgKey) != msgKeySize {
+		return nil, ErrInvalidMsgKeySize
+	}
+
+	aesKey, aesIV := deriveKeys(authKey, msgKey, d)
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext = make([]byte, len(ciphertext))
+	if err := ige.DecryptBlocks(block, aesIV, plaintext, ciphertext); err != nil {
+		return nil, err
+	}
+
+	want := msgKeyLarge(authKey, plaintext, d)[8:24]
+	if subtle.ConstantTimeCompare(want, msgKey) != 1 {
+		return nil, ErrMsgKeyMismatch
+	}
+
+	return plaintext, nil
+}